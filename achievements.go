@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// achievementDefsMu guards achievementDefs so a config reload (SIGHUP or
+// POST /api/achievements/reload) can swap the active definitions while
+// requests are being served.
+var (
+	achievementDefsMu sync.RWMutex
+	achievementDefs   = builtinAchievementDefs
+)
+
+func currentAchievementDefs() []AchievementDef {
+	achievementDefsMu.RLock()
+	defer achievementDefsMu.RUnlock()
+	return achievementDefs
+}
+
+func setAchievementDefs(defs []AchievementDef) {
+	achievementDefsMu.Lock()
+	achievementDefs = defs
+	achievementDefsMu.Unlock()
+}
+
+// MetricExtractor computes an achievement metric's current value from a
+// user's state. Built-in metrics are registered in init(); config files
+// can only reference metrics that have a registered extractor.
+type MetricExtractor func(AppState, time.Time) int
+
+// MetricRegistry maps metric names (as used by AchievementDef.Metric) to
+// the function that computes them, so achievement configs can reference
+// arbitrary numeric metrics without the achievement package knowing about
+// them ahead of time.
+type MetricRegistry struct {
+	mu         sync.RWMutex
+	extractors map[string]MetricExtractor
+}
+
+var metrics = &MetricRegistry{extractors: map[string]MetricExtractor{}}
+
+// RegisterMetric adds or replaces the extractor for name.
+func (r *MetricRegistry) RegisterMetric(name string, fn MetricExtractor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extractors[name] = fn
+}
+
+func (r *MetricRegistry) get(name string) (MetricExtractor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn, ok := r.extractors[name]
+	return fn, ok
+}
+
+func init() {
+	metrics.RegisterMetric("streak", func(s AppState, now time.Time) int {
+		return calculateStreak(s.CheckinDates, now)
+	})
+	metrics.RegisterMetric("total_checkins", func(s AppState, _ time.Time) int {
+		return len(s.CheckinDates)
+	})
+	metrics.RegisterMetric("productive_weeks", func(s AppState, _ time.Time) int {
+		return countProductiveWeeks(s.WeeklyCheckins)
+	})
+	metrics.RegisterMetric("skill_modules", func(s AppState, _ time.Time) int { return s.SkillModules })
+	metrics.RegisterMetric("xp", func(s AppState, _ time.Time) int { return s.XP })
+	metrics.RegisterMetric("projects", func(s AppState, _ time.Time) int { return s.Projects })
+	metrics.RegisterMetric("bug_fixes", func(s AppState, _ time.Time) int { return s.BugFixes })
+	metrics.RegisterMetric("reflections", func(s AppState, _ time.Time) int { return s.Reflections })
+	metrics.RegisterMetric("git_commits", func(s AppState, _ time.Time) int { return s.GitCommits })
+}
+
+// LoadAchievements reads achievement definitions from a config file so
+// categories, tier targets, and XP rewards can change without a rebuild.
+// JSON is fully supported; YAML is detected by extension but rejected with
+// a clear error for now since no YAML parser is vendored in this build —
+// convert the file to JSON until one is.
+func LoadAchievements(path string) ([]AchievementDef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("%s: YAML achievement configs aren't supported in this build (no YAML parser vendored); use a .json file", path)
+	case ".json", "":
+		// fall through
+	default:
+		return nil, fmt.Errorf("%s: unsupported achievement config extension %q", path, ext)
+	}
+
+	var defs []AchievementDef
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := validateAchievementDefs(defs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return defs, nil
+}
+
+func validateAchievementDefs(defs []AchievementDef) error {
+	if len(defs) == 0 {
+		return fmt.Errorf("no achievement definitions")
+	}
+	seen := map[string]bool{}
+	for _, def := range defs {
+		if def.ID == "" {
+			return fmt.Errorf("achievement definition missing id")
+		}
+		if seen[def.ID] {
+			return fmt.Errorf("duplicate achievement id %q", def.ID)
+		}
+		seen[def.ID] = true
+		if _, ok := metrics.get(def.Metric); !ok {
+			return fmt.Errorf("achievement %q references unknown metric %q", def.ID, def.Metric)
+		}
+		if len(def.Tiers) == 0 {
+			return fmt.Errorf("achievement %q has no tiers", def.ID)
+		}
+	}
+	return nil
+}
+
+// reloadAchievements re-reads achievementsPath and atomically swaps the
+// active definitions. An empty achievementsPath is a no-op so the built-in
+// defaults stay in place. If the path is set but the file doesn't exist, the
+// os.ErrNotExist error is returned and the current definitions are kept;
+// newApp ignores that error so startup works before a config file exists.
+func (a *App) reloadAchievements() error {
+	if a.achievementsPath == "" {
+		return nil
+	}
+	defs, err := LoadAchievements(a.achievementsPath)
+	if err != nil {
+		return err
+	}
+	setAchievementDefs(defs)
+	return nil
+}
+
+// watchReloadSignal reloads achievement definitions on SIGHUP, e.g. `kill
+// -HUP <pid>` after editing achievementsPath, without restarting the server.
+func (a *App) watchReloadSignal() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := a.reloadAchievements(); err != nil {
+				log.Printf("achievement config reload failed: %v", err)
+				continue
+			}
+			log.Printf("achievement config reloaded from %s", a.achievementsPath)
+		}
+	}()
+}
+
+func (a *App) handleReloadAchievements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if err := a.reloadAchievements(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"achievements": len(currentAchievementDefs())})
+}
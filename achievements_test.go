@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateAchievementDefs(t *testing.T) {
+	valid := []AchievementDef{
+		{ID: "habit_streak", Metric: "streak", Tiers: []TierDef{{Name: "bronze", Target: 1}}},
+	}
+	if err := validateAchievementDefs(valid); err != nil {
+		t.Fatalf("validateAchievementDefs(valid) = %v, want nil", err)
+	}
+
+	cases := []struct {
+		name string
+		defs []AchievementDef
+	}{
+		{"empty", nil},
+		{"missing id", []AchievementDef{{Metric: "streak", Tiers: []TierDef{{Name: "bronze", Target: 1}}}}},
+		{"duplicate id", []AchievementDef{
+			{ID: "a", Metric: "streak", Tiers: []TierDef{{Name: "bronze", Target: 1}}},
+			{ID: "a", Metric: "xp", Tiers: []TierDef{{Name: "bronze", Target: 1}}},
+		}},
+		{"unknown metric", []AchievementDef{{ID: "a", Metric: "does_not_exist", Tiers: []TierDef{{Name: "bronze", Target: 1}}}}},
+		{"no tiers", []AchievementDef{{ID: "a", Metric: "streak"}}},
+	}
+	for _, c := range cases {
+		if err := validateAchievementDefs(c.defs); err == nil {
+			t.Errorf("validateAchievementDefs(%s) succeeded, want error", c.name)
+		}
+	}
+}
+
+func TestLoadAchievementsJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "achievements.json")
+	content := `[{"id":"a","name":"A","category":"cat","description":"d","metric":"xp","tiers":[{"name":"bronze","target":10}]}]`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	defs, err := LoadAchievements(path)
+	if err != nil {
+		t.Fatalf("LoadAchievements: %v", err)
+	}
+	if len(defs) != 1 || defs[0].ID != "a" {
+		t.Fatalf("LoadAchievements returned %+v", defs)
+	}
+}
+
+func TestLoadAchievementsRejectsYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "achievements.yaml")
+	if err := os.WriteFile(path, []byte("id: a\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := LoadAchievements(path); err == nil {
+		t.Fatal("LoadAchievements accepted a .yaml file, want an explicit unsupported-format error")
+	}
+}
+
+func TestLoadAchievementsRejectsInvalidDefs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "achievements.json")
+	content := `[{"id":"a","metric":"does_not_exist","tiers":[{"name":"bronze","target":1}]}]`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := LoadAchievements(path); err == nil {
+		t.Fatal("LoadAchievements accepted a definition referencing an unknown metric")
+	}
+}
+
+func TestMetricRegistryRegisterAndGet(t *testing.T) {
+	registry := &MetricRegistry{extractors: map[string]MetricExtractor{}}
+	registry.RegisterMetric("custom", func(s AppState, now time.Time) int { return 7 })
+
+	fn, ok := registry.get("custom")
+	if !ok {
+		t.Fatal("expected custom metric to be registered")
+	}
+	if got := fn(AppState{}, time.Time{}); got != 7 {
+		t.Fatalf("custom metric = %d, want 7", got)
+	}
+
+	if _, ok := registry.get("missing"); ok {
+		t.Fatal("expected missing metric to be absent")
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User is an account that owns its own AppState.
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Salt         string    `json:"salt"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserStore keeps the registry of accounts (not their achievement state)
+// persisted as a single small JSON file. Achievement state is stored
+// separately per user, see App.sessionFor.
+type UserStore struct {
+	mu     sync.RWMutex
+	path   string
+	byID   map[string]*User
+	byName map[string]string // username -> id
+}
+
+func newUserStore(path string) (*UserStore, error) {
+	store := &UserStore{
+		path:   path,
+		byID:   map[string]*User{},
+		byName: map[string]string{},
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *UserStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var users []*User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range users {
+		s.byID[u.ID] = u
+		s.byName[u.Username] = u.ID
+	}
+	return nil
+}
+
+func (s *UserStore) saveLocked() error {
+	users := make([]*User, 0, len(s.byID))
+	for _, u := range s.byID {
+		users = append(users, u)
+	}
+	payload, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, payload, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+var (
+	errUsernameTaken = errors.New("用户名已被占用")
+	errWrongPassword = errors.New("用户名或密码错误")
+)
+
+// dummyAuthSalt/dummyAuthHash give authenticate something to hash against
+// when the username doesn't exist, so a lookup miss still pays the same
+// PBKDF2 cost as a real wrong-password check. Without this, an unknown
+// username would return before hashing while a known one with the wrong
+// password would hash first — two different response times an attacker
+// could use to enumerate valid usernames even if the error text matched.
+var (
+	dummyAuthSalt = "0000000000000000000000000000000"
+	dummyAuthHash = hashPassword("", dummyAuthSalt)
+)
+
+func (s *UserStore) register(username, password string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return nil, errors.New("用户名和密码不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.byName[username]; exists {
+		return nil, errUsernameTaken
+	}
+
+	salt, err := randomSalt()
+	if err != nil {
+		return nil, err
+	}
+	user := &User{
+		ID:           newUserID(),
+		Username:     username,
+		Salt:         salt,
+		PasswordHash: hashPassword(password, salt),
+		CreatedAt:    time.Now(),
+	}
+	s.byID[user.ID] = user
+	s.byName[username] = user.ID
+	if err := s.saveLocked(); err != nil {
+		delete(s.byID, user.ID)
+		delete(s.byName, username)
+		return nil, err
+	}
+	return user, nil
+}
+
+// authenticate always hashes password, whether or not username exists, and
+// always returns errWrongPassword on any failure. Both are there so a
+// lookup miss and a wrong password are indistinguishable to the caller, by
+// timing or by message — otherwise either signal lets an attacker enumerate
+// valid usernames.
+func (s *UserStore) authenticate(username, password string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byName[strings.TrimSpace(username)]
+	salt, wantHash := dummyAuthSalt, dummyAuthHash
+	var user *User
+	if ok {
+		user = s.byID[id]
+		salt, wantHash = user.Salt, user.PasswordHash
+	}
+
+	gotHash := hashPassword(password, salt)
+	if !ok || subtle.ConstantTimeCompare([]byte(gotHash), []byte(wantHash)) != 1 {
+		return nil, errWrongPassword
+	}
+	return user, nil
+}
+
+func (s *UserStore) byUserID(id string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.byID[id]
+	return u, ok
+}
+
+func randomSalt() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func newUserID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// passwordHashIterations is the PBKDF2 work factor. golang.org/x/crypto
+// (bcrypt, pbkdf2) isn't vendored in this build, so this hand-rolls
+// PBKDF2-HMAC-SHA256 from crypto/hmac + crypto/sha256 instead of shipping a
+// bare, unstretched SHA-256 hash that's cheap to brute-force offline if
+// users.json ever leaks.
+const passwordHashIterations = 200_000
+
+func hashPassword(password, salt string) string {
+	return hex.EncodeToString(pbkdf2HMACSHA256([]byte(password), []byte(salt), passwordHashIterations, sha256.Size))
+}
+
+// pbkdf2HMACSHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256 as the PRF.
+func pbkdf2HMACSHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	derived := make([]byte, 0, numBlocks*hashLen)
+	block := make([]byte, 4)
+	for i := 1; i <= numBlocks; i++ {
+		block[0] = byte(i >> 24)
+		block[1] = byte(i >> 16)
+		block[2] = byte(i >> 8)
+		block[3] = byte(i)
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block)
+		u := prf.Sum(nil)
+
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		derived = append(derived, t...)
+	}
+	return derived[:keyLen]
+}
+
+// --- bearer tokens ---
+//
+// A minimal HMAC-SHA256 signed token (header.payload.signature, base64url,
+// unpadded) so login sessions survive restarts without pulling in a JWT
+// dependency. Good enough for "is this the user it claims to be", not a
+// general purpose JOSE implementation.
+
+type tokenClaims struct {
+	Sub string `json:"sub"`
+	Exp int64  `json:"exp"`
+}
+
+const tokenTTL = 7 * 24 * time.Hour
+
+func (a *App) issueToken(userID string) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := tokenClaims{Sub: userID, Exp: time.Now().Add(tokenTTL).Unix()}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signingInput := header + "." + payload
+	return signingInput + "." + a.sign(signingInput), nil
+}
+
+func (a *App) sign(signingInput string) string {
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	errInvalidToken = errors.New("无效的登录凭证")
+	errExpiredToken = errors.New("登录凭证已过期")
+)
+
+func (a *App) verifyToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errInvalidToken
+	}
+	signingInput := parts[0] + "." + parts[1]
+	if subtle.ConstantTimeCompare([]byte(a.sign(signingInput)), []byte(parts[2])) != 1 {
+		return "", errInvalidToken
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return "", errInvalidToken
+	}
+	if time.Now().Unix() > claims.Exp {
+		return "", errExpiredToken
+	}
+	return claims.Sub, nil
+}
+
+type contextKey string
+
+const userIDContextKey contextKey = "userID"
+
+// requireAuth resolves the caller's user from the Authorization: Bearer
+// header before dispatching to next.
+func (a *App) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			writeError(w, http.StatusUnauthorized, "缺少登录凭证")
+			return
+		}
+		userID, err := a.verifyToken(token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if _, ok := a.users.byUserID(userID); !ok {
+			writeError(w, http.StatusUnauthorized, "用户不存在")
+			return
+		}
+		ctx := context.WithValue(r.Context(), userIDContextKey, userID)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func userIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(userIDContextKey).(string)
+	return id
+}
+
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type authResponse struct {
+	Token string `json:"token"`
+	User  struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+func (a *App) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体格式错误")
+		return
+	}
+	user, err := a.users.register(req.Username, req.Password)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	a.respondWithToken(w, user)
+}
+
+func (a *App) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体格式错误")
+		return
+	}
+	user, err := a.users.authenticate(req.Username, req.Password)
+	if err != nil {
+		// Always the same message regardless of whether the username
+		// existed, so the response body can't be used to enumerate
+		// accounts (authenticate already makes the two cases cost the
+		// same amount of time).
+		writeError(w, http.StatusUnauthorized, errWrongPassword.Error())
+		return
+	}
+	a.respondWithToken(w, user)
+}
+
+// loadOrCreateJWTSecret reads the signing secret from path, generating and
+// persisting a random one on first run so tokens survive server restarts.
+func loadOrCreateJWTSecret(path string) ([]byte, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		return data, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, secret, 0o600); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (a *App) respondWithToken(w http.ResponseWriter, user *User) {
+	token, err := a.issueToken(user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "生成登录凭证失败")
+		return
+	}
+	resp := authResponse{Token: token}
+	resp.User.ID = user.ID
+	resp.User.Username = user.Username
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestHashPasswordIsSaltedAndDeterministic(t *testing.T) {
+	h1 := hashPassword("hunter2", "salt-a")
+	h2 := hashPassword("hunter2", "salt-a")
+	if h1 != h2 {
+		t.Fatal("hashPassword must be deterministic for the same password and salt")
+	}
+
+	if hashPassword("hunter2", "salt-b") == h1 {
+		t.Fatal("different salts must produce different hashes")
+	}
+	if hashPassword("hunter3", "salt-a") == h1 {
+		t.Fatal("different passwords must produce different hashes")
+	}
+}
+
+func TestIssueAndVerifyToken(t *testing.T) {
+	app := &App{jwtSecret: []byte("test-secret")}
+
+	token, err := app.issueToken("user-123")
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+
+	userID, err := app.verifyToken(token)
+	if err != nil {
+		t.Fatalf("verifyToken: %v", err)
+	}
+	if userID != "user-123" {
+		t.Fatalf("verifyToken returned %q, want %q", userID, "user-123")
+	}
+}
+
+func TestVerifyTokenRejectsTamperedSignature(t *testing.T) {
+	app := &App{jwtSecret: []byte("test-secret")}
+
+	token, err := app.issueToken("user-123")
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+
+	tampered := token[:len(token)-1] + "x"
+	if _, err := app.verifyToken(tampered); err == nil {
+		t.Fatal("verifyToken accepted a tampered token")
+	}
+}
+
+func TestVerifyTokenRejectsForeignSecret(t *testing.T) {
+	issuer := &App{jwtSecret: []byte("secret-a")}
+	verifier := &App{jwtSecret: []byte("secret-b")}
+
+	token, err := issuer.issueToken("user-123")
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+	if _, err := verifier.verifyToken(token); err == nil {
+		t.Fatal("verifyToken accepted a token signed with a different secret")
+	}
+}
+
+func TestVerifyTokenRejectsExpired(t *testing.T) {
+	app := &App{jwtSecret: []byte("test-secret")}
+
+	claims := tokenClaims{Sub: "user-123", Exp: 1}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signingInput := header + "." + payload
+	token := signingInput + "." + app.sign(signingInput)
+
+	if _, err := app.verifyToken(token); err != errExpiredToken {
+		t.Fatalf("verifyToken error = %v, want %v", err, errExpiredToken)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schedulerTick is how often the scheduler checks for due jobs. Jobs are
+// scheduled with minute granularity, so this doesn't need to be finer.
+const schedulerTick = time.Minute
+
+// ScheduledJob is one entry registered with Scheduler.RegisterJob.
+type ScheduledJob struct {
+	Spec    string    `json:"spec"`
+	NextRun time.Time `json:"next_run"`
+
+	fn   func(*App)
+	next func(time.Time) time.Time
+}
+
+// Scheduler runs periodic maintenance jobs against the App. It supports two
+// spec forms, which is all this project needs and avoids pulling in a full
+// cron expression dependency:
+//
+//	"@every <duration>"  e.g. "@every 1h", parsed with time.ParseDuration
+//	"@daily HH:MM"        runs once a day at the given local time
+type Scheduler struct {
+	app *App
+
+	mu   sync.Mutex
+	jobs []*ScheduledJob
+}
+
+func newScheduler(app *App) *Scheduler {
+	return &Scheduler{app: app}
+}
+
+// RegisterJob parses spec and adds fn to the schedule. Callers can use this
+// to add custom jobs (webhooks, reports, ...) alongside the built-in ones.
+func (s *Scheduler) RegisterJob(spec string, fn func(*App)) error {
+	next, err := parseSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &ScheduledJob{
+		Spec:    spec,
+		NextRun: next(time.Now()),
+		fn:      fn,
+		next:    next,
+	})
+	return nil
+}
+
+// Jobs returns a snapshot of registered jobs and their next run time, for
+// GET /api/jobs.
+func (s *Scheduler) Jobs() []ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ScheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, ScheduledJob{Spec: j.Spec, NextRun: j.NextRun})
+	}
+	return out
+}
+
+// run starts the scheduler's tick loop. Call it in its own goroutine.
+func (s *Scheduler) run() {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.runDue(now)
+	}
+}
+
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	due := make([]*ScheduledJob, 0)
+	for _, j := range s.jobs {
+		if !now.Before(j.NextRun) {
+			due = append(due, j)
+			j.NextRun = j.next(now)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, j := range due {
+		j.fn(s.app)
+	}
+}
+
+func parseSpec(spec string) (func(time.Time) time.Time, error) {
+	switch {
+	case strings.HasPrefix(spec, "@every "):
+		d, err := time.ParseDuration(strings.TrimPrefix(spec, "@every "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid @every spec %q: %w", spec, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid @every spec %q: duration must be positive", spec)
+		}
+		return func(now time.Time) time.Time { return now.Add(d) }, nil
+
+	case strings.HasPrefix(spec, "@daily "):
+		hour, minute, err := parseClock(strings.TrimPrefix(spec, "@daily "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid @daily spec %q: %w", spec, err)
+		}
+		return func(now time.Time) time.Time {
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			return next
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported job spec %q (use \"@every <duration>\" or \"@daily HH:MM\")", spec)
+	}
+}
+
+func parseClock(clock string) (hour, minute int, err error) {
+	parts := strings.Split(clock, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected HH:MM")
+	}
+	hour, err = strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, fmt.Errorf("invalid hour %q", parts[0])
+	}
+	minute, err = strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid minute %q", parts[1])
+	}
+	return hour, minute, nil
+}
+
+// --- built-in jobs ---
+//
+// These only act on sessions already loaded in memory (a.sessions), i.e.
+// users who have been active since the server started. That mirrors the
+// lazy, per-user loading sessionFor already does elsewhere.
+
+// streakGraceDaysPerWeek lets a user miss this many check-in days per
+// 7-day block without losing their streak. See calculateStreak.
+// It's a package-level var rather than a const so main can make it
+// configurable (the --streak-grace-days flag); it's only ever written once,
+// before the server starts accepting requests, so it needs no locking.
+var streakGraceDaysPerWeek = 1
+
+// finalizeWeeklyRollups runs once a day; on the day an ISO week just ended
+// (yesterday was a Sunday) it finalizes that week's WeeklyCheckins bucket
+// for every active user and publishes EventProductiveWeekFinalized for
+// anyone who crossed productiveWeekThreshold. Running daily rather than
+// weekly keeps it on the same "@daily HH:MM" spec the rest of cron.go uses;
+// the weekday guard is what makes it act only once per completed week.
+func finalizeWeeklyRollups(a *App) {
+	finalizeWeeklyRollupsAt(a, time.Now())
+}
+
+// finalizeWeeklyRollupsAt is finalizeWeeklyRollups with now passed in, so
+// tests can exercise a specific weekday without depending on wall-clock time.
+func finalizeWeeklyRollupsAt(a *App, now time.Time) {
+	yesterday := now.AddDate(0, 0, -1)
+	if yesterday.Weekday() != time.Sunday {
+		return
+	}
+	weekKey := isoWeekKey(yesterday)
+
+	a.sessionsMu.Lock()
+	sessions := make(map[string]*userSession, len(a.sessions))
+	for id, s := range a.sessions {
+		sessions[id] = s
+	}
+	a.sessionsMu.Unlock()
+
+	for userID, session := range sessions {
+		session.mu.Lock()
+		count := session.state.WeeklyCheckins[weekKey]
+		session.mu.Unlock()
+
+		if count < productiveWeekThreshold {
+			continue
+		}
+		a.events.Publish(&Event{
+			Kind:    EventProductiveWeekFinalized,
+			UserID:  userID,
+			At:      now,
+			WeekKey: weekKey,
+			Count:   count,
+		})
+	}
+}
+
+// remindAtRiskStreaks logs every active user who has a streak but hasn't
+// checked in yet today, so a future webhook/push listener has something to
+// hang a reminder off of.
+func remindAtRiskStreaks(a *App) {
+	now := time.Now()
+	today := isoDate(now)
+
+	a.sessionsMu.Lock()
+	sessions := make(map[string]*userSession, len(a.sessions))
+	for id, s := range a.sessions {
+		sessions[id] = s
+	}
+	a.sessionsMu.Unlock()
+
+	for userID, session := range sessions {
+		session.mu.Lock()
+		checkedInToday := session.state.CheckinDates[today]
+		streak := calculateStreak(session.state.CheckinDates, now)
+		session.mu.Unlock()
+
+		if streak > 0 && !checkedInToday {
+			log.Printf("streak reminder: user=%s streak=%d is at risk of resetting today", userID, streak)
+		}
+	}
+}
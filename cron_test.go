@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSpecEvery(t *testing.T) {
+	next, err := parseSpec("@every 1h")
+	if err != nil {
+		t.Fatalf("parseSpec: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	want := now.Add(time.Hour)
+	if got := next(now); !got.Equal(want) {
+		t.Fatalf("next(now) = %v, want %v", got, want)
+	}
+}
+
+func TestParseSpecDaily(t *testing.T) {
+	next, err := parseSpec("@daily 08:00")
+	if err != nil {
+		t.Fatalf("parseSpec: %v", err)
+	}
+
+	before := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	if got := next(before); !got.Equal(want) {
+		t.Fatalf("next(before 08:00) = %v, want %v", got, want)
+	}
+
+	after := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	wantNext := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	if got := next(after); !got.Equal(wantNext) {
+		t.Fatalf("next(after 08:00) = %v, want %v", got, wantNext)
+	}
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	cases := []string{"", "@weekly mon", "@every -1h", "@daily 25:00", "@daily 9"}
+	for _, spec := range cases {
+		if _, err := parseSpec(spec); err == nil {
+			t.Errorf("parseSpec(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestCalculateStreakAllowsConfiguredGrace(t *testing.T) {
+	orig := streakGraceDaysPerWeek
+	defer func() { streakGraceDaysPerWeek = orig }()
+
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC) // Saturday
+	checkins := map[string]bool{}
+	for i := 0; i < 7; i++ {
+		if i == 3 {
+			continue // one missed day
+		}
+		checkins[isoDate(now.AddDate(0, 0, -i))] = true
+	}
+
+	streakGraceDaysPerWeek = 1
+	if got := calculateStreak(checkins, now); got != 6 {
+		t.Fatalf("with grace=1, calculateStreak = %d, want 6 (the missed day is skipped over, not counted)", got)
+	}
+
+	streakGraceDaysPerWeek = 0
+	if got := calculateStreak(checkins, now); got != 3 {
+		t.Fatalf("with grace=0, calculateStreak = %d, want 3 (stops at the missed day)", got)
+	}
+}
+
+func TestCalculateStreakEmpty(t *testing.T) {
+	if got := calculateStreak(map[string]bool{}, time.Now()); got != 0 {
+		t.Fatalf("calculateStreak(empty) = %d, want 0", got)
+	}
+}
+
+func TestFinalizeWeeklyRollupsPublishesOnlyAfterWeekEnds(t *testing.T) {
+	bus := newEventBus()
+	published := make(chan *Event, 1)
+	bus.AddListener(EventProductiveWeekFinalized, func(evt *Event) error {
+		published <- evt
+		return nil
+	})
+
+	go bus.run()
+
+	app := &App{events: bus, sessions: map[string]*userSession{}}
+
+	// Saturday: yesterday (Friday) didn't end an ISO week, so nothing fires.
+	saturday := time.Date(2026, 1, 10, 1, 0, 0, 0, time.UTC)
+	weekKey := isoWeekKey(saturday.AddDate(0, 0, -1))
+	app.sessions["user-1"] = &userSession{state: AppState{WeeklyCheckins: map[string]int{weekKey: productiveWeekThreshold}}}
+	finalizeWeeklyRollupsAt(app, saturday)
+	select {
+	case evt := <-published:
+		t.Fatalf("unexpected event published before week end: %+v", evt)
+	default:
+	}
+
+	// Monday: yesterday (Sunday) ended the ISO week tracked above.
+	monday := time.Date(2026, 1, 12, 1, 0, 0, 0, time.UTC)
+	lastWeekKey := isoWeekKey(monday.AddDate(0, 0, -1))
+	app.sessions["user-1"] = &userSession{state: AppState{WeeklyCheckins: map[string]int{lastWeekKey: productiveWeekThreshold}}}
+	app.sessions["user-2"] = &userSession{state: AppState{WeeklyCheckins: map[string]int{lastWeekKey: productiveWeekThreshold - 1}}}
+	finalizeWeeklyRollupsAt(app, monday)
+
+	select {
+	case evt := <-published:
+		if evt.UserID != "user-1" {
+			t.Fatalf("published event for %q, want user-1", evt.UserID)
+		}
+		if evt.WeekKey != lastWeekKey {
+			t.Fatalf("published WeekKey %q, want %q", evt.WeekKey, lastWeekKey)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an EventProductiveWeekFinalized event for user-1")
+	}
+
+	select {
+	case evt := <-published:
+		t.Fatalf("user-2 is below threshold and should not have published: %+v", evt)
+	default:
+	}
+}
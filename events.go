@@ -0,0 +1,199 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// EventKind identifies the kind of domain event flowing through the EventBus.
+type EventKind string
+
+const (
+	EventActionApplied           EventKind = "action_applied"
+	EventCheckinRecorded         EventKind = "checkin_recorded"
+	EventTierUnlocked            EventKind = "tier_unlocked"
+	EventLevelUp                 EventKind = "level_up"
+	EventProductiveWeekFinalized EventKind = "productive_week_finalized"
+)
+
+// Event is emitted whenever a user's AppState changes. Only the fields
+// relevant to Kind are populated, mirroring how DashboardResponse flattens
+// unrelated achievement fields rather than using a tagged union.
+type Event struct {
+	Kind   EventKind `json:"kind"`
+	UserID string    `json:"user_id"`
+	At     time.Time `json:"at"`
+
+	ActionKind string `json:"action_kind,omitempty"` // EventActionApplied
+	Amount     int    `json:"amount,omitempty"`      // EventActionApplied
+
+	AchievementID string `json:"achievement_id,omitempty"` // EventTierUnlocked
+	TierName      string `json:"tier_name,omitempty"`      // EventTierUnlocked
+
+	Level int `json:"level,omitempty"` // EventLevelUp
+
+	WeekKey string `json:"week_key,omitempty"` // EventProductiveWeekFinalized
+	Count   int    `json:"count,omitempty"`    // EventProductiveWeekFinalized
+}
+
+// eventQueueSize bounds how far the worker can fall behind before Publish
+// starts blocking the caller.
+const eventQueueSize = 256
+
+// EventBus is an in-process, channel-backed event queue drained by a single
+// worker goroutine. Handlers publish events after a state mutation is
+// durably saved; listeners run achievement side effects (notifications,
+// audit logging) off the request path instead of inline in the handler.
+type EventBus struct {
+	queue chan *Event
+
+	mu        sync.RWMutex
+	listeners map[EventKind][]func(*Event) error
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{
+		queue:     make(chan *Event, eventQueueSize),
+		listeners: map[EventKind][]func(*Event) error{},
+	}
+}
+
+// AddListener registers fn to run whenever an event of kind is drained from
+// the queue. Listener errors are logged, not propagated, since the
+// publishing request has already completed by the time they run.
+func (b *EventBus) AddListener(kind EventKind, fn func(*Event) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners[kind] = append(b.listeners[kind], fn)
+}
+
+// Publish enqueues evt for asynchronous processing. It blocks if the queue
+// is full, which is preferable to silently dropping achievement events.
+func (b *EventBus) Publish(evt *Event) {
+	b.queue <- evt
+}
+
+// run drains the queue until it is closed. Call it in its own goroutine.
+func (b *EventBus) run() {
+	for evt := range b.queue {
+		b.dispatch(evt)
+	}
+}
+
+func (b *EventBus) dispatch(evt *Event) {
+	b.mu.RLock()
+	listeners := append([]func(*Event) error(nil), b.listeners[evt.Kind]...)
+	b.mu.RUnlock()
+
+	for _, listener := range listeners {
+		runListener(listener, evt)
+	}
+}
+
+// runListener invokes listener with panic recovery. Without this, a single
+// listener panicking (built-in or added later via AddListener) would take
+// down the only worker goroutine; run would exit, Publish would then block
+// forever once queue fills, and every handler calling it while holding a
+// user's session.mu would wedge.
+func runListener(listener func(*Event) error, evt *Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("event listener panicked: kind=%s user=%s recovered=%v", evt.Kind, evt.UserID, r)
+		}
+	}()
+	if err := listener(evt); err != nil {
+		log.Printf("event listener failed: kind=%s user=%s err=%v", evt.Kind, evt.UserID, err)
+	}
+}
+
+// metricSnapshot captures every achievement's current metric value. Take
+// one before mutating AppState and one after, then diff with
+// publishTierUnlocks: AppState's map fields are reference types, so
+// snapshotting values up front avoids the "before" copy silently changing
+// along with the live state.
+func metricSnapshot(state *AppState, now time.Time) map[string]int {
+	defs := currentAchievementDefs()
+	snapshot := make(map[string]int, len(defs))
+	for _, def := range defs {
+		snapshot[def.ID] = metricValue(*state, def.Metric, now)
+	}
+	return snapshot
+}
+
+// publishTierUnlocks compares before/after metricSnapshots and publishes
+// EventTierUnlocked for every tier newly crossed.
+func publishTierUnlocks(bus *EventBus, userID string, before, after map[string]int, now time.Time) {
+	for _, def := range currentAchievementDefs() {
+		beforeTier := tierIndex(def, before[def.ID])
+		afterTier := tierIndex(def, after[def.ID])
+		for t := beforeTier; t < afterTier; t++ {
+			bus.Publish(&Event{
+				Kind:          EventTierUnlocked,
+				UserID:        userID,
+				At:            now,
+				AchievementID: def.ID,
+				TierName:      def.Tiers[t].Name,
+			})
+		}
+	}
+}
+
+func tierIndex(def AchievementDef, value int) int {
+	tier := 0
+	for i, t := range def.Tiers {
+		if value >= t.Target {
+			tier = i + 1
+		}
+	}
+	return tier
+}
+
+func levelFor(xp int) int {
+	return xp/levelStepXP + 1
+}
+
+// --- built-in listeners ---
+
+// logTierUnlock is the default notification hook for EventTierUnlocked: it
+// writes to the server log. Callers wanting webhooks or push notifications
+// register additional listeners alongside it via AddListener.
+func logTierUnlock(evt *Event) error {
+	log.Printf("achievement unlocked: user=%s achievement=%s tier=%s", evt.UserID, evt.AchievementID, evt.TierName)
+	return nil
+}
+
+// logLevelUp is the default notification hook for EventLevelUp.
+func logLevelUp(evt *Event) error {
+	log.Printf("level up: user=%s level=%d", evt.UserID, evt.Level)
+	return nil
+}
+
+// logProductiveWeekFinalized is the default notification hook for
+// EventProductiveWeekFinalized.
+func logProductiveWeekFinalized(evt *Event) error {
+	log.Printf("productive week achieved: user=%s week=%s check_ins=%d", evt.UserID, evt.WeekKey, evt.Count)
+	return nil
+}
+
+// registerBuiltinListeners wires the notification and audit listeners every
+// App starts with. Audit events are appended through the same Store used
+// for state, so history is queryable via QueryEvents / GET /api/history.
+// Additional listeners (webhooks, badge minting, ...) can be added
+// afterwards with the same AddListener call.
+func registerBuiltinListeners(bus *EventBus, store Store) {
+	bus.AddListener(EventTierUnlocked, logTierUnlock)
+	bus.AddListener(EventTierUnlocked, func(evt *Event) error {
+		appMetrics.recordTierUnlock(evt.AchievementID, evt.TierName)
+		return nil
+	})
+	bus.AddListener(EventLevelUp, logLevelUp)
+	bus.AddListener(EventProductiveWeekFinalized, logProductiveWeekFinalized)
+
+	auditRecord := func(evt *Event) error {
+		return store.AppendEvent(evt.UserID, *evt)
+	}
+	for _, kind := range []EventKind{EventActionApplied, EventCheckinRecorded, EventTierUnlocked, EventLevelUp, EventProductiveWeekFinalized} {
+		bus.AddListener(kind, auditRecord)
+	}
+}
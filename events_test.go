@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBusDispatchRecoversFromPanic(t *testing.T) {
+	bus := newEventBus()
+
+	var ranAfterPanic bool
+	bus.AddListener(EventLevelUp, func(evt *Event) error {
+		panic("boom")
+	})
+	bus.AddListener(EventLevelUp, func(evt *Event) error {
+		ranAfterPanic = true
+		return nil
+	})
+
+	bus.dispatch(&Event{Kind: EventLevelUp, UserID: "u1"})
+
+	if !ranAfterPanic {
+		t.Fatal("a panicking listener must not prevent later listeners for the same event from running")
+	}
+}
+
+func TestEventBusDispatchLogsListenerError(t *testing.T) {
+	bus := newEventBus()
+
+	called := false
+	bus.AddListener(EventActionApplied, func(evt *Event) error {
+		called = true
+		return errors.New("boom")
+	})
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	bus.dispatch(&Event{Kind: EventActionApplied, UserID: "u1"})
+
+	if !called {
+		t.Fatal("listener should have been invoked")
+	}
+	if !strings.Contains(logs.String(), "event listener failed") {
+		t.Fatalf("listener error was not logged; log output: %q", logs.String())
+	}
+}
+
+func TestEventBusRunSurvivesPanickingListener(t *testing.T) {
+	bus := newEventBus()
+
+	received := make(chan struct{}, 1)
+	bus.AddListener(EventTierUnlocked, func(evt *Event) error {
+		panic("boom")
+	})
+	bus.AddListener(EventCheckinRecorded, func(evt *Event) error {
+		received <- struct{}{}
+		return nil
+	})
+
+	go bus.run()
+
+	bus.Publish(&Event{Kind: EventTierUnlocked, UserID: "u1"})
+	bus.Publish(&Event{Kind: EventCheckinRecorded, UserID: "u1"})
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("worker goroutine did not survive a panicking listener")
+	}
+}
+
+func TestTierIndex(t *testing.T) {
+	def := AchievementDef{
+		Tiers: []TierDef{
+			{Name: "bronze", Target: 1},
+			{Name: "silver", Target: 5},
+			{Name: "gold", Target: 10},
+		},
+	}
+
+	cases := []struct {
+		value int
+		want  int
+	}{
+		{0, 0},
+		{1, 1},
+		{4, 1},
+		{5, 2},
+		{10, 3},
+		{20, 3},
+	}
+	for _, c := range cases {
+		if got := tierIndex(def, c.value); got != c.want {
+			t.Errorf("tierIndex(%d) = %d, want %d", c.value, got, c.want)
+		}
+	}
+}
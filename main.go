@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,10 @@ import (
 	"time"
 )
 
+// usersDirName is the subdirectory of the data directory holding one state
+// file per user (data/users/<id>.json).
+const usersDirName = "users"
+
 const (
 	levelStepXP = 200
 )
@@ -84,13 +89,31 @@ type DashboardResponse struct {
 	Achievements    []AchievementProgress `json:"achievements"`
 }
 
+// userSession is one user's in-memory state plus the mutex that serializes
+// access to it. Handlers lock a single userSession instead of a global
+// App-wide mutex, so concurrent users don't serialize on each other.
+type userSession struct {
+	mu    sync.Mutex
+	state AppState
+}
+
 type App struct {
-	mu       sync.Mutex
-	state    AppState
-	dataPath string
+	users *UserStore
+	store Store
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*userSession
+
+	jwtSecret []byte
+
+	events *EventBus
+
+	achievementsPath string
+
+	cron *Scheduler
 }
 
-var achievementDefs = []AchievementDef{
+var builtinAchievementDefs = []AchievementDef{
 	{
 		ID:          "habit_streak",
 		Name:        "连续学习",
@@ -217,45 +240,206 @@ func (s *AppState) normalize() {
 	}
 }
 
-func newApp(dataPath string) (*App, error) {
-	app := &App{
-		state:    defaultState(),
-		dataPath: dataPath,
+// Option configures newApp. The zero-value config isn't usable on its own
+// (WithJWTSecret is required); everything else has a sane default.
+type Option func(*appConfig)
+
+type appConfig struct {
+	dataDir          string
+	jwtSecret        []byte
+	achievementsPath string
+	store            Store
+}
+
+// WithDataDir sets the directory the account registry, JWT secret, and
+// (if no WithStore is given) per-user state files live under. Default "data".
+func WithDataDir(dir string) Option {
+	return func(c *appConfig) { c.dataDir = dir }
+}
+
+// WithJWTSecret sets the key bearer tokens are signed with. Required.
+func WithJWTSecret(secret []byte) Option {
+	return func(c *appConfig) { c.jwtSecret = secret }
+}
+
+// WithAchievementsPath points reloadAchievements at a config file. Leaving
+// this unset keeps the built-in achievement definitions.
+func WithAchievementsPath(path string) Option {
+	return func(c *appConfig) { c.achievementsPath = path }
+}
+
+// WithStore overrides the achievement state/event backend. Defaults to a
+// FileStore rooted at dataDir/users if not given.
+func WithStore(store Store) Option {
+	return func(c *appConfig) { c.store = store }
+}
+
+// newApp wires up a multi-user server: dataDir/users.json holds the account
+// registry, and achievement state is persisted through a Store (a FileStore
+// rooted at dataDir/users by default). jwtSecret signs bearer tokens handed
+// out on login.
+func newApp(opts ...Option) (*App, error) {
+	cfg := &appConfig{dataDir: "data"}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if len(cfg.jwtSecret) == 0 {
+		return nil, errors.New("newApp: WithJWTSecret is required")
 	}
-	if err := os.MkdirAll(filepath.Dir(dataPath), 0o755); err != nil {
+
+	if cfg.store == nil {
+		usersDir := filepath.Join(cfg.dataDir, usersDirName)
+		if err := os.MkdirAll(usersDir, 0o755); err != nil {
+			return nil, err
+		}
+		cfg.store = NewFileStore(usersDir)
+	}
+
+	users, err := newUserStore(filepath.Join(cfg.dataDir, "users.json"))
+	if err != nil {
 		return nil, err
 	}
-	if err := app.load(); err != nil {
+
+	events := newEventBus()
+	registerBuiltinListeners(events, cfg.store)
+	go events.run()
+
+	app := &App{
+		users:            users,
+		store:            cfg.store,
+		sessions:         map[string]*userSession{},
+		jwtSecret:        cfg.jwtSecret,
+		events:           events,
+		achievementsPath: cfg.achievementsPath,
+	}
+	if err := app.reloadAchievements(); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return nil, err
 	}
+
+	app.cron = newScheduler(app)
+	if err := app.registerBuiltinJobs(); err != nil {
+		return nil, err
+	}
+	go app.cron.run()
+
 	return app, nil
 }
 
-func (a *App) load() error {
-	data, err := os.ReadFile(a.dataPath)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
+// registerBuiltinJobs wires the maintenance jobs every App starts with.
+// Call RegisterJob on app.cron afterwards to add more.
+func (a *App) registerBuiltinJobs() error {
+	if err := a.cron.RegisterJob("@daily 00:05", finalizeWeeklyRollups); err != nil {
+		return err
+	}
+	return a.cron.RegisterJob("@daily 08:00", remindAtRiskStreaks)
+}
+
+// registerMetricsGauges wires the total-users/total-XP/active-streaks
+// gauges into the process-wide Metrics registry. XP and streak totals only
+// cover sessions loaded into memory, same limitation the cron jobs have.
+func (a *App) registerMetricsGauges() {
+	appMetrics.registerGauges(
+		func() int {
+			a.users.mu.RLock()
+			defer a.users.mu.RUnlock()
+			return len(a.users.byID)
+		},
+		func() int {
+			total := 0
+			a.sessionsMu.Lock()
+			defer a.sessionsMu.Unlock()
+			for _, s := range a.sessions {
+				s.mu.Lock()
+				total += s.state.XP
+				s.mu.Unlock()
+			}
+			return total
+		},
+		func() int {
+			now := time.Now()
+			active := 0
+			a.sessionsMu.Lock()
+			defer a.sessionsMu.Unlock()
+			for _, s := range a.sessions {
+				s.mu.Lock()
+				if calculateStreak(s.state.CheckinDates, now) > 0 {
+					active++
+				}
+				s.mu.Unlock()
+			}
+			return active
+		},
+	)
+}
+
+// handleHistory answers GET /api/history?from=&to=&kind= with the calling
+// user's event log, both fields optional RFC3339 timestamps, so dashboards
+// can be rebuilt from history or back-dated corrections checked.
+func (a *App) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
 	}
+
+	filter := EventFilter{Kind: EventKind(r.URL.Query().Get("kind"))}
+	if from := r.URL.Query().Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "from 参数格式错误，需为 RFC3339")
+			return
+		}
+		filter.From = t
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "to 参数格式错误，需为 RFC3339")
+			return
+		}
+		filter.To = t
+	}
+
+	userID := userIDFromContext(r.Context())
+	events, err := a.store.QueryEvents(userID, filter)
 	if err != nil {
-		return err
+		writeError(w, http.StatusInternalServerError, "查询历史记录失败")
+		return
 	}
-	if err := json.Unmarshal(data, &a.state); err != nil {
-		return err
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (a *App) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
 	}
-	a.state.normalize()
-	return nil
+	writeJSON(w, http.StatusOK, a.cron.Jobs())
 }
 
-func (a *App) saveLocked() error {
-	payload, err := json.MarshalIndent(a.state, "", "  ")
-	if err != nil {
-		return err
+// sessionFor returns the (lazily loaded) session for userID, loading its
+// state from the Store on first access and caching it for the process
+// lifetime.
+func (a *App) sessionFor(userID string) (*userSession, error) {
+	a.sessionsMu.Lock()
+	defer a.sessionsMu.Unlock()
+
+	if s, ok := a.sessions[userID]; ok {
+		return s, nil
 	}
-	tmp := a.dataPath + ".tmp"
-	if err := os.WriteFile(tmp, payload, 0o644); err != nil {
-		return err
+
+	state, err := a.store.LoadState(userID)
+	if err != nil {
+		return nil, err
 	}
-	return os.Rename(tmp, a.dataPath)
+	session := &userSession{state: state}
+	a.sessions[userID] = session
+	return session, nil
+}
+
+// saveSessionLocked persists session through the Store. Callers must
+// already hold session.mu.
+func (a *App) saveSessionLocked(userID string, session *userSession) error {
+	return a.store.SaveState(userID, session.state)
 }
 
 func (a *App) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -272,9 +456,14 @@ func (a *App) handleState(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	writeJSON(w, http.StatusOK, a.dashboardLocked(time.Now()))
+	session, err := a.sessionFor(userIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "加载用户数据失败")
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	writeJSON(w, http.StatusOK, dashboardLocked(&session.state, time.Now()))
 }
 
 func (a *App) handleCheckin(w http.ResponseWriter, r *http.Request) {
@@ -283,26 +472,39 @@ func (a *App) handleCheckin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	userID := userIDFromContext(r.Context())
+	session, err := a.sessionFor(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "加载用户数据失败")
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
 
 	now := time.Now()
 	day := isoDate(now)
-	if a.state.CheckinDates[day] {
+	if session.state.CheckinDates[day] {
 		writeError(w, http.StatusConflict, "今天已经打卡过了")
 		return
 	}
 
-	a.state.CheckinDates[day] = true
-	a.state.WeeklyCheckins[isoWeekKey(now)]++
-	a.state.XP += 20
-	a.state.UpdatedAt = now
+	before := metricSnapshot(&session.state, now)
+	levelBefore := levelFor(session.state.XP)
+
+	session.state.CheckinDates[day] = true
+	session.state.WeeklyCheckins[isoWeekKey(now)]++
+	session.state.XP += 20
+	session.state.UpdatedAt = now
 
-	if err := a.saveLocked(); err != nil {
+	if err := a.saveSessionLocked(userID, session); err != nil {
 		writeError(w, http.StatusInternalServerError, "保存数据失败")
 		return
 	}
-	writeJSON(w, http.StatusOK, a.dashboardLocked(now))
+
+	a.events.Publish(&Event{Kind: EventCheckinRecorded, UserID: userID, At: now})
+	a.emitProgressEventsLocked(userID, before, &session.state, levelBefore, now)
+
+	writeJSON(w, http.StatusOK, dashboardLocked(&session.state, now))
 }
 
 func (a *App) handleAction(w http.ResponseWriter, r *http.Request) {
@@ -321,21 +523,47 @@ func (a *App) handleAction(w http.ResponseWriter, r *http.Request) {
 		req.Amount = 1
 	}
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	userID := userIDFromContext(r.Context())
+	session, err := a.sessionFor(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "加载用户数据失败")
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
 
-	if err := a.applyActionLocked(req.Kind, req.Amount); err != nil {
+	now := time.Now()
+	before := metricSnapshot(&session.state, now)
+	levelBefore := levelFor(session.state.XP)
+
+	if err := applyActionLocked(&session.state, req.Kind, req.Amount); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if err := a.saveLocked(); err != nil {
+	if err := a.saveSessionLocked(userID, session); err != nil {
 		writeError(w, http.StatusInternalServerError, "保存数据失败")
 		return
 	}
-	writeJSON(w, http.StatusOK, a.dashboardLocked(time.Now()))
+
+	a.events.Publish(&Event{Kind: EventActionApplied, UserID: userID, At: now, ActionKind: req.Kind, Amount: req.Amount})
+	a.emitProgressEventsLocked(userID, before, &session.state, levelBefore, now)
+
+	writeJSON(w, http.StatusOK, dashboardLocked(&session.state, now))
+}
+
+// emitProgressEventsLocked publishes TierUnlocked/LevelUp events for
+// whatever changed between before and the now-mutated state. Callers must
+// already hold the session's mutex.
+func (a *App) emitProgressEventsLocked(userID string, before map[string]int, state *AppState, levelBefore int, now time.Time) {
+	after := metricSnapshot(state, now)
+	publishTierUnlocks(a.events, userID, before, after, now)
+
+	if levelAfter := levelFor(state.XP); levelAfter > levelBefore {
+		a.events.Publish(&Event{Kind: EventLevelUp, UserID: userID, At: now, Level: levelAfter})
+	}
 }
 
-func (a *App) applyActionLocked(kind string, amount int) error {
+func applyActionLocked(state *AppState, kind string, amount int) error {
 	if amount < 1 {
 		return errors.New("amount 必须大于 0")
 	}
@@ -345,43 +573,45 @@ func (a *App) applyActionLocked(kind string, amount int) error {
 
 	switch kind {
 	case "study_hour":
-		a.state.StudyHours += amount
-		a.state.XP += amount * 15
+		state.StudyHours += amount
+		state.XP += amount * 15
 	case "skill_module":
-		a.state.SkillModules += amount
-		a.state.XP += amount * 35
+		state.SkillModules += amount
+		state.XP += amount * 35
 	case "project":
-		a.state.Projects += amount
-		a.state.XP += amount * 100
+		state.Projects += amount
+		state.XP += amount * 100
 	case "bug_fix":
-		a.state.BugFixes += amount
-		a.state.XP += amount * 25
+		state.BugFixes += amount
+		state.XP += amount * 25
 	case "reflection":
-		a.state.Reflections += amount
-		a.state.XP += amount * 20
+		state.Reflections += amount
+		state.XP += amount * 20
 	case "git_commit":
-		a.state.GitCommits += amount
-		a.state.XP += amount * 8
+		state.GitCommits += amount
+		state.XP += amount * 8
 	default:
 		return fmt.Errorf("未知动作类型: %s", kind)
 	}
 
-	a.state.UpdatedAt = time.Now()
+	appMetrics.recordAction(kind)
+	state.UpdatedAt = time.Now()
 	return nil
 }
 
-func (a *App) dashboardLocked(now time.Time) DashboardResponse {
-	streak := calculateStreak(a.state.CheckinDates, now)
-	totalCheckins := len(a.state.CheckinDates)
-	productiveWeeks := countProductiveWeeks(a.state.WeeklyCheckins)
+func dashboardLocked(state *AppState, now time.Time) DashboardResponse {
+	streak := calculateStreak(state.CheckinDates, now)
+	totalCheckins := len(state.CheckinDates)
+	productiveWeeks := countProductiveWeeks(state.WeeklyCheckins)
 
-	level := a.state.XP/levelStepXP + 1
+	level := state.XP/levelStepXP + 1
 	levelFloor := (level - 1) * levelStepXP
 	levelCeiling := level * levelStepXP
 
-	achievements := make([]AchievementProgress, 0, len(achievementDefs))
-	for _, def := range achievementDefs {
-		value := metricValue(a.state, def.Metric, now)
+	defs := currentAchievementDefs()
+	achievements := make([]AchievementProgress, 0, len(defs))
+	for _, def := range defs {
+		value := metricValue(*state, def.Metric, now)
 		currentTier := 0
 		for i, tier := range def.Tiers {
 			if value >= tier.Target {
@@ -425,17 +655,17 @@ func (a *App) dashboardLocked(now time.Time) DashboardResponse {
 	})
 
 	return DashboardResponse{
-		XP:              a.state.XP,
+		XP:              state.XP,
 		Level:           level,
-		CurrentLevelXP:  a.state.XP - levelFloor,
+		CurrentLevelXP:  state.XP - levelFloor,
 		NextLevelXP:     levelCeiling - levelFloor,
 		Streak:          streak,
-		StudyHours:      a.state.StudyHours,
-		SkillModules:    a.state.SkillModules,
-		Projects:        a.state.Projects,
-		BugFixes:        a.state.BugFixes,
-		Reflections:     a.state.Reflections,
-		GitCommits:      a.state.GitCommits,
+		StudyHours:      state.StudyHours,
+		SkillModules:    state.SkillModules,
+		Projects:        state.Projects,
+		BugFixes:        state.BugFixes,
+		Reflections:     state.Reflections,
+		GitCommits:      state.GitCommits,
 		TotalCheckins:   totalCheckins,
 		ProductiveWeeks: productiveWeeks,
 		Mission:         recommendMission(achievements),
@@ -443,29 +673,14 @@ func (a *App) dashboardLocked(now time.Time) DashboardResponse {
 	}
 }
 
+// metricValue looks up metric in the MetricRegistry so achievement configs
+// can reference any registered metric, built-in or custom.
 func metricValue(state AppState, metric string, now time.Time) int {
-	switch metric {
-	case "streak":
-		return calculateStreak(state.CheckinDates, now)
-	case "total_checkins":
-		return len(state.CheckinDates)
-	case "productive_weeks":
-		return countProductiveWeeks(state.WeeklyCheckins)
-	case "skill_modules":
-		return state.SkillModules
-	case "xp":
-		return state.XP
-	case "projects":
-		return state.Projects
-	case "bug_fixes":
-		return state.BugFixes
-	case "reflections":
-		return state.Reflections
-	case "git_commits":
-		return state.GitCommits
-	default:
+	fn, ok := metrics.get(metric)
+	if !ok {
 		return 0
 	}
+	return fn(state, now)
 }
 
 func recommendMission(achievements []AchievementProgress) string {
@@ -494,16 +709,27 @@ func recommendMission(achievements []AchievementProgress) string {
 	return fmt.Sprintf("主线任务：推进「%s」，还差 %d", best.Name, bestRemaining)
 }
 
+// productiveWeekThreshold is how many check-ins in an ISO week count as
+// "productive". Shared by countProductiveWeeks and finalizeWeeklyRollups so
+// the live metric and the nightly finalization job agree on the threshold.
+const productiveWeekThreshold = 5
+
 func countProductiveWeeks(weekly map[string]int) int {
 	count := 0
 	for _, value := range weekly {
-		if value >= 5 {
+		if value >= productiveWeekThreshold {
 			count++
 		}
 	}
 	return count
 }
 
+// calculateStreak counts consecutive check-in days ending today (or
+// yesterday, if today's check-in hasn't happened yet). It allows up to
+// streakGraceDaysPerWeek missed days in each consecutive 7-day block counted
+// back from that starting day, so a single bad day doesn't reset an
+// otherwise long streak. The allowance resets at every block boundary, so
+// two misses on either side of a boundary can fall less than 7 days apart.
 func calculateStreak(checkins map[string]bool, now time.Time) int {
 	if len(checkins) == 0 {
 		return 0
@@ -515,8 +741,21 @@ func calculateStreak(checkins map[string]bool, now time.Time) int {
 	}
 
 	streak := 0
-	for checkins[isoDate(anchor)] {
-		streak++
+	graceLeft := streakGraceDaysPerWeek
+	daysWalked := 0
+	for {
+		if checkins[isoDate(anchor)] {
+			streak++
+		} else if graceLeft > 0 {
+			graceLeft--
+		} else {
+			break
+		}
+
+		daysWalked++
+		if daysWalked%7 == 0 {
+			graceLeft = streakGraceDaysPerWeek
+		}
 		anchor = anchor.AddDate(0, 0, -1)
 	}
 	return streak
@@ -545,17 +784,67 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
+// newAPIMux builds the mux the main API server listens on. Split out from
+// main so tests can drive the full handler chain (auth middleware, session
+// isolation, ...) through httptest without starting a real listener.
+func newAPIMux(app *App) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", app.handleIndex)
+	mux.HandleFunc("/api/auth/register", app.handleRegister)
+	mux.HandleFunc("/api/auth/login", app.handleLogin)
+	mux.HandleFunc("/api/state", instrumentHandler("state", app.requireAuth(app.handleState)))
+	mux.HandleFunc("/api/checkin", instrumentHandler("checkin", app.requireAuth(app.handleCheckin)))
+	mux.HandleFunc("/api/action", instrumentHandler("action", app.requireAuth(app.handleAction)))
+	mux.HandleFunc("/api/achievements/reload", app.requireAuth(app.handleReloadAchievements))
+	mux.HandleFunc("/api/jobs", app.requireAuth(app.handleJobs))
+	mux.HandleFunc("/api/history", app.requireAuth(app.handleHistory))
+	return mux
+}
+
 func main() {
-	app, err := newApp(filepath.Join("data", "state.json"))
+	metricsAddr := flag.String("metrics-addr", "", "address to serve /metrics and /debug/pprof/* on, separate from the main API (e.g. :9090); if empty, neither is served at all, since /debug/pprof exposes CPU/heap profiling that shouldn't sit on a public, unauthenticated port")
+	streakGraceDays := flag.Int("streak-grace-days", streakGraceDaysPerWeek, "check-in days a user may miss per 7-day block (counted back from today, or yesterday if today has no check-in) without losing their streak")
+	flag.Parse()
+	streakGraceDaysPerWeek = *streakGraceDays
+
+	dataDir := "data"
+	achievementsPath := filepath.Join(dataDir, "achievements.json")
+	jwtSecret, err := loadOrCreateJWTSecret(filepath.Join(dataDir, "jwt.secret"))
+	if err != nil {
+		log.Fatalf("初始化登录密钥失败: %v", err)
+	}
+
+	// Only WithDataDir/WithJWTSecret/WithAchievementsPath are wired here, so
+	// this always runs on the default FileStore. SQLStore and RedisStore
+	// (store.go) are library-level Store implementations for callers who
+	// vendor a SQL driver or Redis client and construct the App themselves
+	// with WithStore(...); this binary doesn't vendor either, so there's no
+	// --store flag selecting them.
+	app, err := newApp(
+		WithDataDir(dataDir),
+		WithJWTSecret(jwtSecret),
+		WithAchievementsPath(achievementsPath),
+	)
 	if err != nil {
 		log.Fatalf("初始化失败: %v", err)
 	}
+	app.watchReloadSignal()
+	app.registerMetricsGauges()
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", app.handleIndex)
-	mux.HandleFunc("/api/state", app.handleState)
-	mux.HandleFunc("/api/checkin", app.handleCheckin)
-	mux.HandleFunc("/api/action", app.handleAction)
+	mux := newAPIMux(app)
+
+	// /debug/pprof lets a caller trigger a 30s CPU profile or dump the heap;
+	// it must never sit on the public, unauthenticated API port. It (and
+	// /metrics) are only served when --metrics-addr opts into a separate
+	// port for them.
+	if *metricsAddr == "" {
+		log.Println("--metrics-addr not set: /metrics and /debug/pprof are disabled")
+	} else {
+		go func() {
+			log.Printf("metrics are running at http://localhost%s/metrics", *metricsAddr)
+			log.Fatal(http.ListenAndServe(*metricsAddr, newMetricsMux()))
+		}()
+	}
 
 	server := &http.Server{
 		Addr:         ":8080",
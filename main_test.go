@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	app, err := newApp(
+		WithDataDir(t.TempDir()),
+		WithJWTSecret([]byte("test-secret")),
+	)
+	if err != nil {
+		t.Fatalf("newApp: %v", err)
+	}
+	return app
+}
+
+func doJSON(t *testing.T, mux http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func registerUser(t *testing.T, mux http.Handler, username, password string) string {
+	t.Helper()
+	rec := doJSON(t, mux, http.MethodPost, "/api/auth/register", "", credentialsRequest{Username: username, Password: password})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register %s: status %d body %s", username, rec.Code, rec.Body.String())
+	}
+	var resp authResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode register response: %v", err)
+	}
+	return resp.Token
+}
+
+// TestUserStateIsolation registers two users, has each act and check in,
+// and asserts neither sees the other's dashboard or state — the core
+// guarantee the multi-user/per-session refactor is supposed to provide.
+func TestUserStateIsolation(t *testing.T) {
+	app := newTestApp(t)
+	mux := newAPIMux(app)
+
+	tokenA := registerUser(t, mux, "alice", "hunter2")
+	tokenB := registerUser(t, mux, "bob", "hunter2")
+
+	rec := doJSON(t, mux, http.MethodPost, "/api/checkin", tokenA, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("alice checkin: status %d body %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(t, mux, http.MethodPost, "/api/action", tokenA, ActionRequest{Kind: "git_commit", Amount: 5})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("alice action: status %d body %s", rec.Code, rec.Body.String())
+	}
+
+	var aliceDash DashboardResponse
+	rec = doJSON(t, mux, http.MethodGet, "/api/state", tokenA, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("alice state: status %d body %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &aliceDash); err != nil {
+		t.Fatalf("decode alice dashboard: %v", err)
+	}
+	if aliceDash.GitCommits != 5 {
+		t.Fatalf("alice git_commits = %d, want 5", aliceDash.GitCommits)
+	}
+	if aliceDash.Streak == 0 {
+		t.Fatal("alice should have a streak of at least 1 after checking in")
+	}
+
+	var bobDash DashboardResponse
+	rec = doJSON(t, mux, http.MethodGet, "/api/state", tokenB, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("bob state: status %d body %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &bobDash); err != nil {
+		t.Fatalf("decode bob dashboard: %v", err)
+	}
+	if bobDash.GitCommits != 0 {
+		t.Fatalf("bob git_commits = %d, want 0 (alice's action must not leak into bob's state)", bobDash.GitCommits)
+	}
+	if bobDash.Streak != 0 {
+		t.Fatalf("bob streak = %d, want 0 (bob never checked in)", bobDash.Streak)
+	}
+	if bobDash.XP == aliceDash.XP {
+		t.Fatal("bob's XP should not match alice's after only alice acted")
+	}
+}
+
+func TestRequireAuthRejectsMissingAndBadTokens(t *testing.T) {
+	app := newTestApp(t)
+	mux := newAPIMux(app)
+
+	rec := doJSON(t, mux, http.MethodGet, "/api/state", "", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("missing token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = doJSON(t, mux, http.MethodGet, "/api/state", "not-a-real-token", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("garbage token: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	token, err := app.issueToken("no-such-user")
+	if err != nil {
+		t.Fatalf("issueToken: %v", err)
+	}
+	rec = doJSON(t, mux, http.MethodGet, "/api/state", token, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token for deleted/unknown user: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleLoginRejectsUnknownUserAndWrongPasswordIdentically(t *testing.T) {
+	app := newTestApp(t)
+	mux := newAPIMux(app)
+
+	registerUser(t, mux, "carol", "correct-horse")
+
+	recUnknown := doJSON(t, mux, http.MethodPost, "/api/auth/login", "", credentialsRequest{Username: "nobody", Password: "whatever"})
+	recWrongPW := doJSON(t, mux, http.MethodPost, "/api/auth/login", "", credentialsRequest{Username: "carol", Password: "wrong"})
+
+	if recUnknown.Code != http.StatusUnauthorized || recWrongPW.Code != http.StatusUnauthorized {
+		t.Fatalf("status codes = %d, %d, want both %d", recUnknown.Code, recWrongPW.Code, http.StatusUnauthorized)
+	}
+	if recUnknown.Body.String() != recWrongPW.Body.String() {
+		t.Fatalf("login error bodies differ (%q vs %q) — this leaks whether a username exists", recUnknown.Body.String(), recWrongPW.Body.String())
+	}
+}
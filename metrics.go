@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultLatencyBuckets mirrors the Prometheus client library's default
+// histogram buckets (seconds), since no such library is vendored here.
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram is a minimal Prometheus-style cumulative histogram: fixed
+// bucket upper bounds, a running sum, and a total count.
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []int64 // counts[i] = observations <= buckets[i]
+	sum     float64
+	count   int64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]int64, len(buckets))}
+}
+
+func (h *histogram) observe(seconds float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sum += seconds
+	h.count++
+	for i, upper := range h.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+}
+
+func (h *histogram) writeTo(w io.Writer, name string, labels string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%sle=\"%g\"} %d\n", name, labelPrefix(labels), upper, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, labelPrefix(labels), h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func labelPrefix(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return labels + ","
+}
+
+// Metrics is a tiny in-process Prometheus-style registry. It's hand-rolled
+// rather than built on prometheus/client_golang, which isn't vendored in
+// this build; the exposition format it writes is the same one that client
+// speaks, so Prometheus can still scrape /metrics.
+type Metrics struct {
+	mu             sync.Mutex
+	actionsByKind  map[string]int64
+	tierUnlocks    map[[2]string]int64 // [achievementID, tierName] -> count
+	handlerLatency map[string]*histogram
+
+	totalUsers    func() int
+	totalXP       func() int
+	activeStreaks func() int
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		actionsByKind:  map[string]int64{},
+		tierUnlocks:    map[[2]string]int64{},
+		handlerLatency: map[string]*histogram{},
+	}
+}
+
+// appMetrics is the process-wide registry. A single App per process makes
+// a package-level registry simpler than threading one through every
+// handler signature, matching the existing achievementDefs/metrics
+// globals' style.
+var appMetrics = newMetrics()
+
+// registerGauges wires the callbacks /metrics reads totals from. Call once
+// the App exists.
+func (m *Metrics) registerGauges(totalUsers, totalXP, activeStreaks func() int) {
+	m.totalUsers = totalUsers
+	m.totalXP = totalXP
+	m.activeStreaks = activeStreaks
+}
+
+func (m *Metrics) recordAction(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actionsByKind[kind]++
+}
+
+func (m *Metrics) recordTierUnlock(achievementID, tierName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tierUnlocks[[2]string{achievementID, tierName}]++
+}
+
+func (m *Metrics) observeHandlerLatency(handler string, seconds float64) {
+	m.mu.Lock()
+	h, ok := m.handlerLatency[handler]
+	if !ok {
+		h = newHistogram(defaultLatencyBuckets)
+		m.handlerLatency[handler] = h
+	}
+	m.mu.Unlock()
+	h.observe(seconds)
+}
+
+// instrumentHandler wraps next to record request latency under handler
+// name, without changing next's signature.
+func instrumentHandler(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next(w, r)
+		appMetrics.observeHandlerLatency(name, time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	kinds := make([]string, 0, len(m.actionsByKind))
+	for kind := range m.actionsByKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	fmt.Fprintln(w, "# HELP achievement_actions_total Actions applied, by kind.")
+	fmt.Fprintln(w, "# TYPE achievement_actions_total counter")
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "achievement_actions_total{kind=\"%s\"} %d\n", kind, m.actionsByKind[kind])
+	}
+
+	tierKeys := make([][2]string, 0, len(m.tierUnlocks))
+	for k := range m.tierUnlocks {
+		tierKeys = append(tierKeys, k)
+	}
+	sort.Slice(tierKeys, func(i, j int) bool {
+		if tierKeys[i][0] != tierKeys[j][0] {
+			return tierKeys[i][0] < tierKeys[j][0]
+		}
+		return tierKeys[i][1] < tierKeys[j][1]
+	})
+	fmt.Fprintln(w, "# HELP achievement_tier_unlocks_total Tier unlocks, by achievement id and tier name.")
+	fmt.Fprintln(w, "# TYPE achievement_tier_unlocks_total counter")
+	for _, k := range tierKeys {
+		fmt.Fprintf(w, "achievement_tier_unlocks_total{achievement_id=\"%s\",tier=\"%s\"} %d\n", k[0], k[1], m.tierUnlocks[k])
+	}
+
+	handlerNames := make([]string, 0, len(m.handlerLatency))
+	for name := range m.handlerLatency {
+		handlerNames = append(handlerNames, name)
+	}
+	sort.Strings(handlerNames)
+	fmt.Fprintln(w, "# HELP achievement_handler_duration_seconds Handler latency in seconds.")
+	fmt.Fprintln(w, "# TYPE achievement_handler_duration_seconds histogram")
+	for _, name := range handlerNames {
+		m.handlerLatency[name].writeTo(w, "achievement_handler_duration_seconds", fmt.Sprintf("handler=\"%s\"", name))
+	}
+	m.mu.Unlock()
+
+	if m.totalUsers != nil {
+		fmt.Fprintln(w, "# HELP achievement_users_total Registered user accounts.")
+		fmt.Fprintln(w, "# TYPE achievement_users_total gauge")
+		fmt.Fprintf(w, "achievement_users_total %d\n", m.totalUsers())
+	}
+	if m.totalXP != nil {
+		fmt.Fprintln(w, "# HELP achievement_xp_total Total XP across active users.")
+		fmt.Fprintln(w, "# TYPE achievement_xp_total gauge")
+		fmt.Fprintf(w, "achievement_xp_total %d\n", m.totalXP())
+	}
+	if m.activeStreaks != nil {
+		fmt.Fprintln(w, "# HELP achievement_active_streaks Active users with a non-zero streak.")
+		fmt.Fprintln(w, "# TYPE achievement_active_streaks gauge")
+		fmt.Fprintf(w, "achievement_active_streaks %d\n", m.activeStreaks())
+	}
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	appMetrics.writeTo(w)
+}
+
+// registerPprof mounts net/http/pprof's handlers on mux under /debug/pprof/,
+// the same paths http.DefaultServeMux would register them on.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// newMetricsMux builds the mux a --metrics-addr server listens on.
+func newMetricsMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handleMetrics)
+	registerPprof(mux)
+	return mux
+}
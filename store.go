@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// EventFilter narrows QueryEvents results. A zero Kind matches every kind;
+// a zero From/To leaves that bound open.
+type EventFilter struct {
+	From time.Time
+	To   time.Time
+	Kind EventKind
+}
+
+func (f EventFilter) matches(evt Event) bool {
+	if f.Kind != "" && evt.Kind != f.Kind {
+		return false
+	}
+	if !f.From.IsZero() && evt.At.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && evt.At.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// Store persists achievement state and the append-only event log it's
+// derived from, so dashboards can be rebuilt from history and back-dated
+// corrections are possible. newApp takes one via WithStore; FileStore is
+// the default.
+type Store interface {
+	LoadState(userID string) (AppState, error)
+	SaveState(userID string, state AppState) error
+	AppendEvent(userID string, evt Event) error
+	QueryEvents(userID string, filter EventFilter) ([]Event, error)
+}
+
+// --- file-backed store (default) ---
+
+// FileStore keeps one state snapshot and one append-only event log per
+// user under usersDir, following the same snapshot-file layout the
+// single-user version of this server used.
+type FileStore struct {
+	usersDir string
+}
+
+func NewFileStore(usersDir string) *FileStore {
+	return &FileStore{usersDir: usersDir}
+}
+
+func (s *FileStore) statePath(userID string) string {
+	return filepath.Join(s.usersDir, userID+".json")
+}
+
+func (s *FileStore) eventsPath(userID string) string {
+	return filepath.Join(s.usersDir, userID+".events.jsonl")
+}
+
+func (s *FileStore) LoadState(userID string) (AppState, error) {
+	state := defaultState()
+	data, err := os.ReadFile(s.statePath(userID))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return AppState{}, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return AppState{}, err
+	}
+	state.normalize()
+	return state, nil
+}
+
+func (s *FileStore) SaveState(userID string, state AppState) error {
+	payload, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.statePath(userID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, payload, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) AppendEvent(userID string, evt Event) error {
+	line, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.eventsPath(userID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStore) QueryEvents(userID string, filter EventFilter) ([]Event, error) {
+	data, err := os.ReadFile(s.eventsPath(userID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Event
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var evt Event
+		if err := decoder.Decode(&evt); err != nil {
+			break
+		}
+		if filter.matches(evt) {
+			matched = append(matched, evt)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].At.Before(matched[j].At) })
+	return matched, nil
+}
+
+// --- SQL-backed store ---
+
+// SQLStore persists through database/sql. It uses "?" bind parameters and
+// avoids dialect-specific upsert syntax (no "ON CONFLICT ... DO UPDATE",
+// no "ON DUPLICATE KEY UPDATE"), so it works with any driver that accepts
+// "?" placeholders — sqlite and mysql drivers, but NOT lib/pq or pgx, which
+// expect "$1, $2, ..." instead. Use a placeholder-rewriting wrapper driver
+// if you need this against Postgres. NewSQLStore expects db to already be
+// open with a driver imported for side effects by main, e.g.
+// `_ "github.com/mattn/go-sqlite3"`.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	store := &SQLStore{db: db}
+	if err := store.migrate(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *SQLStore) migrate() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_state (
+			user_id    TEXT PRIMARY KEY,
+			state_json TEXT NOT NULL
+		)`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS events (
+			user_id    TEXT NOT NULL,
+			at         TIMESTAMP NOT NULL,
+			kind       TEXT NOT NULL,
+			event_json TEXT NOT NULL
+		)`)
+	return err
+}
+
+func (s *SQLStore) LoadState(userID string) (AppState, error) {
+	state := defaultState()
+	var stateJSON string
+	err := s.db.QueryRow(`SELECT state_json FROM user_state WHERE user_id = ?`, userID).Scan(&stateJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return AppState{}, err
+	}
+	if err := json.Unmarshal([]byte(stateJSON), &state); err != nil {
+		return AppState{}, err
+	}
+	state.normalize()
+	return state, nil
+}
+
+// SaveState does an UPDATE-then-INSERT instead of a single upsert statement:
+// "ON CONFLICT ... DO UPDATE" (sqlite/postgres) and "ON DUPLICATE KEY
+// UPDATE" (mysql) aren't portable across the drivers this store targets, so
+// two plain statements are used instead of dialect-specific SQL.
+func (s *SQLStore) SaveState(userID string, state AppState) error {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec(`UPDATE user_state SET state_json = ? WHERE user_id = ?`, string(payload), userID)
+	if err != nil {
+		return err
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return err
+	} else if rows > 0 {
+		return nil
+	}
+
+	_, err = s.db.Exec(`INSERT INTO user_state (user_id, state_json) VALUES (?, ?)`, userID, string(payload))
+	return err
+}
+
+func (s *SQLStore) AppendEvent(userID string, evt Event) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO events (user_id, at, kind, event_json) VALUES (?, ?, ?, ?)`,
+		userID, evt.At, evt.Kind, string(payload))
+	return err
+}
+
+func (s *SQLStore) QueryEvents(userID string, filter EventFilter) ([]Event, error) {
+	query := `SELECT event_json FROM events WHERE user_id = ?`
+	args := []any{userID}
+	if !filter.From.IsZero() {
+		query += ` AND at >= ?`
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += ` AND at <= ?`
+		args = append(args, filter.To)
+	}
+	if filter.Kind != "" {
+		query += ` AND kind = ?`
+		args = append(args, filter.Kind)
+	}
+	query += ` ORDER BY at ASC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var eventJSON string
+		if err := rows.Scan(&eventJSON); err != nil {
+			return nil, err
+		}
+		var evt Event
+		if err := json.Unmarshal([]byte(eventJSON), &evt); err != nil {
+			return nil, err
+		}
+		events = append(events, evt)
+	}
+	return events, rows.Err()
+}
+
+// --- Redis-backed store ---
+
+// RedisClient is the minimal subset of a Redis client this store needs.
+// Depending on an interface instead of a concrete client (e.g.
+// redis/go-redis/v9) keeps this file buildable without that module
+// vendored; pass an adapter wrapping the real client's *redis.Client.
+type RedisClient interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+	RPush(ctx context.Context, key string, value string) error
+	LRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+}
+
+// RedisStore stores one state key ("state:<userID>") and one list key
+// ("events:<userID>") per user.
+type RedisStore struct {
+	client RedisClient
+}
+
+func NewRedisStore(client RedisClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) LoadState(userID string) (AppState, error) {
+	state := defaultState()
+	raw, err := s.client.Get(context.Background(), redisStateKey(userID))
+	if errors.Is(err, errRedisNil) {
+		return state, nil
+	}
+	if err != nil {
+		return AppState{}, err
+	}
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return AppState{}, err
+	}
+	state.normalize()
+	return state, nil
+}
+
+func (s *RedisStore) SaveState(userID string, state AppState) error {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(context.Background(), redisStateKey(userID), string(payload))
+}
+
+func (s *RedisStore) AppendEvent(userID string, evt Event) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return s.client.RPush(context.Background(), redisEventsKey(userID), string(payload))
+}
+
+func (s *RedisStore) QueryEvents(userID string, filter EventFilter) ([]Event, error) {
+	raw, err := s.client.LRange(context.Background(), redisEventsKey(userID), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, item := range raw {
+		var evt Event
+		if err := json.Unmarshal([]byte(item), &evt); err != nil {
+			return nil, err
+		}
+		if filter.matches(evt) {
+			events = append(events, evt)
+		}
+	}
+	return events, nil
+}
+
+func redisStateKey(userID string) string  { return fmt.Sprintf("state:%s", userID) }
+func redisEventsKey(userID string) string { return fmt.Sprintf("events:%s", userID) }
+
+// errRedisNil mirrors redis.Nil so a RedisClient adapter can return it for
+// a missing key without this file importing the redis package.
+var errRedisNil = errors.New("redis: nil")
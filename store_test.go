@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileStoreSaveAndLoadState(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+
+	state := defaultState()
+	state.XP = 42
+	state.CheckinDates["2026-01-01"] = true
+
+	if err := store.SaveState("user-1", state); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	loaded, err := store.LoadState("user-1")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if loaded.XP != 42 {
+		t.Fatalf("loaded XP = %d, want 42", loaded.XP)
+	}
+	if !loaded.CheckinDates["2026-01-01"] {
+		t.Fatal("loaded state missing check-in date")
+	}
+}
+
+func TestFileStoreLoadStateMissingUserReturnsDefault(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+
+	state, err := store.LoadState("nobody")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if state.XP != 0 {
+		t.Fatalf("default state XP = %d, want 0", state.XP)
+	}
+}
+
+func TestFileStoreAppendAndQueryEvents(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Kind: EventCheckinRecorded, UserID: "user-1", At: base},
+		{Kind: EventActionApplied, UserID: "user-1", At: base.Add(time.Hour), ActionKind: "git_commit"},
+		{Kind: EventCheckinRecorded, UserID: "user-1", At: base.Add(2 * time.Hour)},
+	}
+	for _, evt := range events {
+		if err := store.AppendEvent("user-1", evt); err != nil {
+			t.Fatalf("AppendEvent: %v", err)
+		}
+	}
+
+	all, err := store.QueryEvents("user-1", EventFilter{})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("QueryEvents returned %d events, want 3", len(all))
+	}
+
+	checkins, err := store.QueryEvents("user-1", EventFilter{Kind: EventCheckinRecorded})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(checkins) != 2 {
+		t.Fatalf("QueryEvents(kind) returned %d events, want 2", len(checkins))
+	}
+
+	since := store
+	filtered, err := since.QueryEvents("user-1", EventFilter{From: base.Add(90 * time.Minute)})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(filtered) != 1 {
+		t.Fatalf("QueryEvents(from) returned %d events, want 1", len(filtered))
+	}
+}
+
+func TestEventFilterMatches(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	evt := Event{Kind: EventLevelUp, At: now}
+
+	cases := []struct {
+		name   string
+		filter EventFilter
+		want   bool
+	}{
+		{"empty filter matches everything", EventFilter{}, true},
+		{"kind mismatch", EventFilter{Kind: EventTierUnlocked}, false},
+		{"kind match", EventFilter{Kind: EventLevelUp}, true},
+		{"before from", EventFilter{From: now.Add(time.Minute)}, false},
+		{"after to", EventFilter{To: now.Add(-time.Minute)}, false},
+		{"within range", EventFilter{From: now.Add(-time.Hour), To: now.Add(time.Hour)}, true},
+	}
+	for _, c := range cases {
+		if got := c.filter.matches(evt); got != c.want {
+			t.Errorf("%s: matches() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}